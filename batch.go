@@ -0,0 +1,45 @@
+package forensiq
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+	"golang.org/x/sync/singleflight"
+)
+
+// CheckResult bundles the outcome of a single item from CheckBatch or a
+// Runner, since each item can fail independently of the others.
+type CheckResult struct {
+	Response CheckResponse
+	Err      error
+}
+
+// CheckBatch runs Check concurrently for every item in reqs, preserving
+// order in the returned slice. Duplicate items (same IP/UserAgent/SellerID)
+// whose lookups are in flight at the same time share one upstream call via
+// singleflight; a duplicate that starts after that call has returned makes
+// its own call (or is served by the cache, if InitCache was called). Per-item
+// failures are reported in CheckResult.Err; the returned error is non-nil
+// only if ctx was canceled before every item completed.
+func (f *Forensiq) CheckBatch(ctx context.Context, reqs []CheckRequest) ([]CheckResult, error) {
+	results := make([]CheckResult, len(reqs))
+
+	var (
+		wg sync.WaitGroup
+		sf singleflight.Group
+	)
+	wg.Add(len(reqs))
+	for i, creq := range reqs {
+		go func(i int, creq CheckRequest) {
+			defer wg.Done()
+			v, err, _ := sf.Do(cacheKey(creq), func() (interface{}, error) {
+				return f.Check(ctx, creq)
+			})
+			cresp, _ := v.(CheckResponse)
+			results[i] = CheckResult{Response: cresp, Err: err}
+		}(i, creq)
+	}
+	wg.Wait()
+
+	return results, ctx.Err()
+}
@@ -0,0 +1,127 @@
+package forensiq
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// countingServer stubs the /check endpoint, counting how many requests
+// actually reach it. delay holds the response open briefly so that
+// concurrently-submitted duplicate lookups have time to register with
+// singleflight as followers of the in-flight leader before it completes.
+func countingServer(delay time.Duration) (*httptest.Server, *int32) {
+	var calls int32
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"nonSuspect":true}`))
+	})
+	return httptest.NewServer(m), &calls
+}
+
+func TestCheckBatchCoalescesDuplicates(t *testing.T) {
+	ts, calls := countingServer(20 * time.Millisecond)
+	defer ts.Close()
+	f := &Forensiq{ClientKey: "123abc", Host: ts.URL}
+
+	reqs := make([]CheckRequest, 10)
+	for i := range reqs {
+		reqs[i] = CheckRequest{UserAgent: "same"}
+	}
+
+	results, err := f.CheckBatch(context.Background(), reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			t.Fatalf("result %d: %v", i, res.Err)
+		}
+		if !res.Response.NonSuspect {
+			t.Errorf("result %d: NonSuspect want true got false", i)
+		}
+	}
+	// singleflight only folds together calls that are concurrently in
+	// flight, so exactly-one is inherently timing-dependent; assert the
+	// coalescing had an effect instead of pinning an exact count.
+	if got := atomic.LoadInt32(calls); got >= int32(len(reqs)) {
+		t.Errorf("server calls: want < %d (coalesced), got %d", len(reqs), got)
+	}
+}
+
+func TestRunnerSingleflight(t *testing.T) {
+	ts, calls := countingServer(20 * time.Millisecond)
+	defer ts.Close()
+	f := &Forensiq{ClientKey: "123abc", Host: ts.URL}
+
+	const n = 10
+	r := NewRunner(f, RunnerOptions{MaxInFlight: 4, QueueSize: n})
+	defer r.Stop()
+
+	chans := make([]<-chan CheckResult, n)
+	for i := range chans {
+		ch, err := r.Submit(context.Background(), CheckRequest{UserAgent: "dup"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		chans[i] = ch
+	}
+	for i, ch := range chans {
+		res := <-ch
+		if res.Err != nil {
+			t.Fatalf("result %d: %v", i, res.Err)
+		}
+	}
+
+	// Same caveat as TestCheckBatchCoalescesDuplicates: singleflight only
+	// coalesces calls already in flight, so assert loosely.
+	if got := atomic.LoadInt32(calls); got >= int32(n) {
+		t.Errorf("server calls: want < %d (coalesced), got %d", n, got)
+	}
+}
+
+func TestRunnerQueueFull(t *testing.T) {
+	ts, _ := countingServer(0)
+	defer ts.Close()
+	f := &Forensiq{ClientKey: "123abc", Host: ts.URL}
+
+	r := NewRunner(f, RunnerOptions{MaxInFlight: 1, QueueSize: 0})
+	defer r.Stop()
+
+	var err error
+	for i := 0; i < 20; i++ {
+		if _, err = r.Submit(context.Background(), CheckRequest{SubID: "distinct"}); err == ErrQueueFull {
+			break
+		}
+	}
+	if err != ErrQueueFull {
+		t.Errorf("err: want %v got %v", ErrQueueFull, err)
+	}
+}
+
+func BenchmarkCheckBatchDuplicate(b *testing.B) {
+	ts, _ := countingServer(0)
+	defer ts.Close()
+	f := &Forensiq{ClientKey: "123abc", Host: ts.URL}
+
+	reqs := make([]CheckRequest, 100)
+	for i := range reqs {
+		reqs[i] = CheckRequest{UserAgent: "same"}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := f.CheckBatch(context.Background(), reqs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package forensiq
+
+import (
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breaker is a minimal closed/open/half-open circuit breaker: it trips after
+// threshold consecutive failures within window, short-circuits further
+// requests while open, and lets a single probe through after cooldown.
+type breaker struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	mu          sync.Mutex
+	state       breakerState
+	failures    int
+	windowStart time.Time
+	openedAt    time.Time
+}
+
+func newBreaker(threshold int, window, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, window: window, cooldown: cooldown}
+}
+
+// allow reports whether a request may proceed. It transitions open ->
+// half-open once cooldown has elapsed, letting exactly one probe through;
+// enteredHalfOpen is true exactly on that transition so the caller can emit
+// a state-transition stat.
+func (b *breaker) allow() (ok bool, enteredHalfOpen bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false, false
+		}
+		b.state = breakerHalfOpen
+		return true, true
+	case breakerHalfOpen:
+		return false, false
+	default:
+		return true, false
+	}
+}
+
+// recordSuccess closes the breaker. reset is true if it was not already
+// closed, so the caller can emit a state-transition stat.
+func (b *breaker) recordSuccess() (reset bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	reset = b.state != breakerClosed
+	b.state = breakerClosed
+	b.failures = 0
+	return reset
+}
+
+// recordFailure counts a failure, tripping the breaker open once threshold
+// consecutive failures land within window, or immediately if the failure was
+// a half-open probe. tripped is true exactly on that transition.
+func (b *breaker) recordFailure() (tripped bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.trip()
+		return true
+	}
+
+	now := time.Now()
+	if b.windowStart.IsZero() || now.Sub(b.windowStart) > b.window {
+		b.windowStart = now
+		b.failures = 0
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.trip()
+		return true
+	}
+	return false
+}
+
+// trip must be called with b.mu held.
+func (b *breaker) trip() {
+	b.state = breakerOpen
+	b.openedAt = time.Now()
+	b.failures = 0
+}
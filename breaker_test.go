@@ -0,0 +1,52 @@
+package forensiq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerTripsOnThreshold(t *testing.T) {
+	b := newBreaker(2, time.Minute, time.Hour)
+
+	if tripped := b.recordFailure(); tripped {
+		t.Fatal("tripped after first failure, want not yet")
+	}
+	if tripped := b.recordFailure(); !tripped {
+		t.Fatal("want tripped on reaching threshold")
+	}
+
+	if ok, enteredHalfOpen := b.allow(); ok || enteredHalfOpen {
+		t.Errorf("allow() while open and within cooldown: want (false, false) got (%v, %v)", ok, enteredHalfOpen)
+	}
+}
+
+func TestBreakerHalfOpenThenReset(t *testing.T) {
+	b := newBreaker(1, time.Minute, time.Millisecond)
+	if tripped := b.recordFailure(); !tripped {
+		t.Fatal("want tripped on reaching threshold")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	ok, enteredHalfOpen := b.allow()
+	if !ok || !enteredHalfOpen {
+		t.Errorf("allow() after cooldown: want (true, true) got (%v, %v)", ok, enteredHalfOpen)
+	}
+
+	if reset := b.recordSuccess(); !reset {
+		t.Error("want reset true when closing from half-open")
+	}
+	if reset := b.recordSuccess(); reset {
+		t.Error("want reset false when already closed")
+	}
+}
+
+func TestBreakerHalfOpenProbeFailureRetrips(t *testing.T) {
+	b := newBreaker(1, time.Minute, time.Millisecond)
+	b.recordFailure()
+	time.Sleep(2 * time.Millisecond)
+	b.allow()
+
+	if tripped := b.recordFailure(); !tripped {
+		t.Error("want tripped true when a half-open probe fails")
+	}
+}
@@ -0,0 +1,130 @@
+package forensiq
+
+import (
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/rs/xlog"
+)
+
+// defaultQuarantineTTL is the TTL applied to negative results (network
+// errors or non-200 responses) when InitCache is called, so that a Forensiq
+// outage degrades into a short quarantine instead of a request storm against
+// the API.
+const defaultQuarantineTTL = 30 * time.Second
+
+// cacheEntry is what gets stored in the LRU for a given key.
+type cacheEntry struct {
+	resp    CheckResponse
+	err     error
+	expires time.Time
+}
+
+// cache is the optional TTL+LRU layer installed in front of Check by
+// InitCache. A nil *cache (the zero value of Forensiq) disables caching
+// entirely.
+type cache struct {
+	lru           *lru.Cache
+	ttl           time.Duration
+	quarantineTTL time.Duration
+	log           xlog.Logger
+	evictions     int64
+}
+
+// takeEvictions returns and resets the number of capacity-based evictions
+// observed since the last call. Lazy-expiry removals (get) and InvalidateIP
+// are deliberate, not evictions, and are logged separately rather than
+// counted here.
+func (c *cache) takeEvictions() int64 {
+	return atomic.SwapInt64(&c.evictions, 0)
+}
+
+func (c *cache) get(key string) (cacheEntry, bool) {
+	v, ok := c.lru.Get(key)
+	if !ok {
+		return cacheEntry{}, false
+	}
+	entry := v.(cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.lru.Remove(key)
+		if c.log != nil {
+			c.log.Debugf("forensiq: cache entry expired%v", xlog.F{"key": key})
+		}
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *cache) set(key string, resp CheckResponse, err error) {
+	ttl := c.ttl
+	if err != nil {
+		ttl = c.quarantineTTL
+	}
+	// Add's own return value, not an onEvicted callback, is used to count
+	// evictions: the callback fires for every removal (including the
+	// deliberate ones above and in InvalidateIP), which would conflate them
+	// with genuine size-based evictions.
+	if evicted := c.lru.Add(key, cacheEntry{resp: resp, err: err, expires: time.Now().Add(ttl)}); evicted {
+		atomic.AddInt64(&c.evictions, 1)
+		if c.log != nil {
+			c.log.Debugf("forensiq: cache evicted an entry for capacity%v", xlog.F{"new_key": key})
+		}
+	}
+}
+
+// cacheKey derives the cache key for a CheckRequest: the IP, UserAgent and
+// SellerID. IP alone is enough to make InvalidateIP precise since it is
+// always the key's first segment.
+func cacheKey(creq CheckRequest) string {
+	return creq.IP.String() + "|" + creq.UserAgent + "|" + creq.SellerID
+}
+
+// InitCache installs a bounded, TTL-aware LRU cache of size entries in front
+// of Check so that repeated lookups for the same IP/UserAgent/SellerID are
+// served locally instead of hitting the Forensiq API. Entries live for ttl;
+// negative results (network errors or non-200 responses) are quarantined for
+// a shorter defaultQuarantineTTL, overridable via SetQuarantineTTL. Calling
+// InitCache again replaces the existing cache.
+func (f *Forensiq) InitCache(size int, ttl time.Duration, logger xlog.Logger) error {
+	c := &cache{
+		ttl:           ttl,
+		quarantineTTL: defaultQuarantineTTL,
+		log:           logger,
+	}
+	l, err := lru.New(size)
+	if err != nil {
+		return err
+	}
+	c.lru = l
+	f.cache = c
+	return nil
+}
+
+// SetQuarantineTTL overrides the TTL used to cache negative results. It has
+// no effect until InitCache has been called.
+func (f *Forensiq) SetQuarantineTTL(ttl time.Duration) {
+	if f.cache != nil {
+		f.cache.quarantineTTL = ttl
+	}
+}
+
+// InvalidateIP evicts every cached entry for ip, regardless of the
+// UserAgent/SellerID it was keyed with. It is a no-op if InitCache was not
+// called.
+func (f *Forensiq) InvalidateIP(ip net.IP) {
+	if f.cache == nil {
+		return
+	}
+	prefix := ip.String() + "|"
+	for _, k := range f.cache.lru.Keys() {
+		if key, ok := k.(string); ok && strings.HasPrefix(key, prefix) {
+			f.cache.lru.Remove(key)
+			if f.cache.log != nil {
+				f.cache.log.Debugf("forensiq: cache invalidated entry%v", xlog.F{"key": key})
+			}
+		}
+	}
+}
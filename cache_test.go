@@ -0,0 +1,174 @@
+package forensiq
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/xlog"
+	"golang.org/x/net/context"
+)
+
+func TestCheckCacheHit(t *testing.T) {
+	var calls int32
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"nonSuspect":true,"riskScore":0,"timeMs":10}`))
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	f := &Forensiq{ClientKey: "123abc", Host: ts.URL}
+	if err := f.InitCache(128, time.Minute, xlog.NopLogger); err != nil {
+		t.Fatal(err)
+	}
+
+	creq := CheckRequest{UserAgent: "ua", SellerID: "seller"}
+	for i := 0; i < 3; i++ {
+		if _, err := f.Check(context.Background(), creq); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("server calls: want 1 got %d", got)
+	}
+}
+
+func TestCheckCacheQuarantinesErrors(t *testing.T) {
+	var calls int32
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	f := &Forensiq{ClientKey: "123abc", Host: ts.URL}
+	if err := f.InitCache(128, time.Minute, xlog.NopLogger); err != nil {
+		t.Fatal(err)
+	}
+	f.SetQuarantineTTL(time.Hour)
+
+	creq := CheckRequest{}
+	for i := 0; i < 3; i++ {
+		if _, err := f.Check(context.Background(), creq); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("server calls: want 1 got %d", got)
+	}
+}
+
+func TestCheckCacheQuarantinesOnErrorSynthesizedResponses(t *testing.T) {
+	var calls int32
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	f := &Forensiq{ClientKey: "123abc", Host: ts.URL}
+	if err := f.InitCache(128, time.Minute, xlog.NopLogger); err != nil {
+		t.Fatal(err)
+	}
+	f.SetQuarantineTTL(time.Hour)
+	f.SetPolicy(Policy{OnError: PolicyDeny})
+
+	creq := CheckRequest{}
+	for i := 0; i < 3; i++ {
+		cresp, err := f.Check(context.Background(), creq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cresp.RiskScore != 100 {
+			t.Errorf("cresp.RiskScore: want 100 got %d", cresp.RiskScore)
+		}
+	}
+
+	// The underlying failure, not the PolicyDeny-synthesized success, must be
+	// what the cache quarantines: otherwise it would be cached for the full
+	// TTL and keep denying long after Forensiq recovers.
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("server calls: want 1 got %d", got)
+	}
+}
+
+func TestCacheEvictionsCountOnlyCapacity(t *testing.T) {
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"nonSuspect":true}`))
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	f := &Forensiq{ClientKey: "123abc", Host: ts.URL}
+	if err := f.InitCache(1, time.Minute, xlog.NopLogger); err != nil {
+		t.Fatal(err)
+	}
+
+	ip1, ip2 := net.ParseIP("1.1.1.1"), net.ParseIP("2.2.2.2")
+	if _, err := f.Check(context.Background(), CheckRequest{IP: ip1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// InvalidateIP removes an entry deliberately; it must not be counted as a
+	// capacity eviction.
+	f.InvalidateIP(ip1)
+	if got := f.cache.takeEvictions(); got != 0 {
+		t.Errorf("evictions after InvalidateIP: want 0 got %d", got)
+	}
+
+	if _, err := f.Check(context.Background(), CheckRequest{IP: ip1}); err != nil {
+		t.Fatal(err)
+	}
+	// Cache holds 1 entry; adding a second must evict the first for capacity.
+	// The second entry goes through set directly: Check drains the counter
+	// itself (to emit forensiq.cache:evict), so it would read 0 here.
+	f.cache.set(cacheKey(CheckRequest{IP: ip2}), CheckResponse{NonSuspect: true}, nil)
+	if got := f.cache.takeEvictions(); got != 1 {
+		t.Errorf("evictions after capacity overflow: want 1 got %d", got)
+	}
+}
+
+func TestInvalidateIP(t *testing.T) {
+	var calls int32
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"nonSuspect":true,"riskScore":0,"timeMs":10}`))
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	f := &Forensiq{ClientKey: "123abc", Host: ts.URL}
+	if err := f.InitCache(128, time.Minute, xlog.NopLogger); err != nil {
+		t.Fatal(err)
+	}
+
+	ip := net.ParseIP("1.2.3.4")
+	creq := CheckRequest{IP: ip}
+	if _, err := f.Check(context.Background(), creq); err != nil {
+		t.Fatal(err)
+	}
+	f.InvalidateIP(ip)
+	if _, err := f.Check(context.Background(), creq); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("server calls: want 2 got %d", got)
+	}
+}
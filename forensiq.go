@@ -3,6 +3,7 @@ package forensiq
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -27,6 +28,9 @@ type (
 		Host string
 
 		httpClient *http.Client
+		cache      *cache
+		policy     *Policy
+		breaker    *breaker
 	}
 
 	// CheckRequest represents a request to the Forensiq API
@@ -101,6 +105,32 @@ var (
 	ErrInvalidClientKey = errors.New("the client key was not accepted by forensiq")
 )
 
+// transientError marks an error from check as retryable: a transport-level
+// failure or a 5xx response, as opposed to a decode failure or a client
+// misconfiguration, which retrying cannot fix. checkResilient uses it to
+// decide what counts against retries and the circuit breaker; it is never
+// returned to callers directly, see unwrapTransient.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+
+// isTransient reports whether err was classified as retryable by check.
+func isTransient(err error) bool {
+	_, ok := err.(*transientError)
+	return ok
+}
+
+// unwrapTransient returns the error check actually encountered, stripping
+// the transientError marker so callers never see the internal wrapper type.
+func unwrapTransient(err error) error {
+	if te, ok := err.(*transientError); ok {
+		return te.err
+	}
+	return err
+}
+
 // New returns a new Forensiq initialized with given host and clientKey and use
 // http.DefaultClient as the HTTP client.
 func New(host, clientKey string) *Forensiq {
@@ -117,8 +147,37 @@ func (f *Forensiq) SetHTTPClient(hc *http.Client) {
 	f.httpClient = hc
 }
 
-// Check get the riskScore and aggregate characteristics.
+// Check get the riskScore and aggregate characteristics. If InitCache was
+// called, a cached response for the same IP/UserAgent/SellerID is returned
+// when present instead of calling the API.
 func (f *Forensiq) Check(ctx context.Context, creq CheckRequest) (CheckResponse, error) {
+	if f.cache == nil {
+		return f.applyPolicy(f.checkResilient(ctx, creq))
+	}
+
+	sts := xstats.FromContext(ctx)
+	key := cacheKey(creq)
+	if entry, ok := f.cache.get(key); ok {
+		sts.Increment("forensiq.cache:hit", 1)
+		return f.applyPolicy(entry.resp, entry.err)
+	}
+	sts.Increment("forensiq.cache:miss", 1)
+
+	// Cache the raw outcome, before OnError synthesizes a response, so a
+	// genuine failure is quarantined for quarantineTTL even when OnError
+	// masks it from the caller; otherwise a synthesized PolicyAllow/
+	// PolicyDeny response would be cached for the full TTL and keep
+	// returning stale results well after Forensiq recovers.
+	cresp, err := f.checkResilient(ctx, creq)
+	f.cache.set(key, cresp, err)
+	if evicted := f.cache.takeEvictions(); evicted > 0 {
+		sts.Increment("forensiq.cache:evict", float64(evicted))
+	}
+	return f.applyPolicy(cresp, err)
+}
+
+// check performs a single, uncached Check API call.
+func (f *Forensiq) check(ctx context.Context, creq CheckRequest) (CheckResponse, error) {
 	var (
 		uri   *url.URL
 		cresp CheckResponse
@@ -150,7 +209,7 @@ func (f *Forensiq) Check(ctx context.Context, creq CheckRequest) (CheckResponse,
 		begin := time.Now()
 		resp, err := ctxhttp.Do(ctx, f.httpClient, req)
 		if err != nil {
-			return CheckResponse{}, err
+			return CheckResponse{}, &transientError{err: err}
 		}
 		defer resp.Body.Close()
 		sts.Timing("forensiq.request_time", time.Since(begin),
@@ -162,6 +221,13 @@ func (f *Forensiq) Check(ctx context.Context, creq CheckRequest) (CheckResponse,
 			log.Errorf("client key is invalid%v", xlog.F{"client_key": f.ClientKey})
 			return CheckResponse{}, ErrInvalidClientKey
 		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			log.Errorf("forensiq returned a server error%v", xlog.F{"status_code": resp.StatusCode})
+			return CheckResponse{}, &transientError{err: fmt.Errorf("forensiq: server returned status %d", resp.StatusCode)}
+		}
+		if resp.StatusCode != http.StatusOK {
+			return CheckResponse{}, fmt.Errorf("forensiq: server returned status %d", resp.StatusCode)
+		}
 		if err := json.NewDecoder(resp.Body).Decode(&cresp); err != nil {
 			return CheckResponse{}, err
 		}
@@ -0,0 +1,160 @@
+// Package forensiqhttp provides net/http middleware that gates requests on
+// the risk score returned by Forensiq.Check.
+package forensiqhttp
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/dailymotion/forensiq"
+	"github.com/rs/xstats"
+
+	"golang.org/x/net/context"
+)
+
+// Options configures Middleware.
+type Options struct {
+	// RiskThreshold is the score at or above which a request is considered
+	// fraudulent by the default BlockOn. Unused if BlockOn is set.
+	RiskThreshold int
+	// BlockOn decides whether a CheckResponse should block the request.
+	// Defaults to RiskScore >= RiskThreshold || AutomatedTraffic || Proxy.
+	BlockOn func(forensiq.CheckResponse) bool
+	// OnBlock handles requests BlockOn rejects. Defaults to a bare 403.
+	OnBlock http.HandlerFunc
+	// Timeout bounds how long Check is allowed to take per request. Zero
+	// means no timeout beyond the request's own context.
+	Timeout time.Duration
+	// FailOpen lets requests through when Check returns an error (API down,
+	// timeout) instead of routing them to OnBlock.
+	FailOpen bool
+	// Bypass, when non-nil, skips Check entirely for requests it approves
+	// (health checks, allowlisted callers, ...).
+	Bypass func(*http.Request) bool
+	// SellerID extracts CheckRequest.SellerID from the incoming request.
+	SellerID func(*http.Request) string
+	// Campaign extracts CheckRequest.Campaign from the incoming request.
+	Campaign func(*http.Request) string
+	// RequestType extracts CheckRequest.RequestType ("click" or "display",
+	// required by the API) from the incoming request. Defaults to always
+	// returning "display", the common case for a gating middleware.
+	RequestType func(*http.Request) string
+}
+
+// contextKey is unexported, the standard net/http idiom for context keys, to
+// keep it from colliding with keys set by other packages. FromContext is the
+// supported way to read the value back.
+type contextKey int
+
+const checkResponseKey contextKey = 0
+
+// FromContext returns the CheckResponse the middleware stored for this
+// request, if any.
+func FromContext(ctx context.Context) (forensiq.CheckResponse, bool) {
+	cresp, ok := ctx.Value(checkResponseKey).(forensiq.CheckResponse)
+	return cresp, ok
+}
+
+// DefaultBlockOn returns the default BlockOn used when Options.BlockOn is
+// nil: RiskScore >= threshold || AutomatedTraffic || Proxy.
+func DefaultBlockOn(threshold int) func(forensiq.CheckResponse) bool {
+	return func(cresp forensiq.CheckResponse) bool {
+		return cresp.RiskScore >= threshold || cresp.AutomatedTraffic || cresp.Proxy
+	}
+}
+
+func defaultOnBlock(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusForbidden)
+}
+
+func defaultRequestType(*http.Request) string {
+	return "display"
+}
+
+// Middleware runs f.Check for each incoming request and routes it to
+// OnBlock when BlockOn reports it as fraudulent. On success, the resulting
+// CheckResponse is injected into the request context and can be read back
+// with FromContext in downstream handlers.
+func Middleware(f *forensiq.Forensiq, opts Options) func(http.Handler) http.Handler {
+	if opts.BlockOn == nil {
+		opts.BlockOn = DefaultBlockOn(opts.RiskThreshold)
+	}
+	if opts.OnBlock == nil {
+		opts.OnBlock = defaultOnBlock
+	}
+	if opts.RequestType == nil {
+		opts.RequestType = defaultRequestType
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if opts.Bypass != nil && opts.Bypass(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// checkCtx bounds only the Check call below; the request's own
+			// context is left untouched for the downstream handler.
+			checkCtx := r.Context()
+			if opts.Timeout > 0 {
+				var cancel context.CancelFunc
+				checkCtx, cancel = context.WithTimeout(checkCtx, opts.Timeout)
+				defer cancel()
+			}
+
+			creq := forensiq.CheckRequest{
+				IP:          remoteIP(r),
+				UserAgent:   r.UserAgent(),
+				URL:         refererURL(r),
+				RequestType: opts.RequestType(r),
+			}
+			if opts.SellerID != nil {
+				creq.SellerID = opts.SellerID(r)
+			}
+			if opts.Campaign != nil {
+				creq.Campaign = opts.Campaign(r)
+			}
+
+			sts := xstats.FromContext(checkCtx)
+			cresp, err := f.Check(checkCtx, creq)
+			if err != nil {
+				if opts.FailOpen {
+					sts.Increment("forensiqhttp.check:fail_open", 1)
+					next.ServeHTTP(w, r)
+					return
+				}
+				sts.Increment("forensiqhttp.check:error", 1)
+				opts.OnBlock(w, r)
+				return
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), checkResponseKey, cresp))
+
+			if opts.BlockOn(cresp) {
+				sts.Increment("forensiqhttp.check:block", 1)
+				opts.OnBlock(w, r)
+				return
+			}
+
+			sts.Increment("forensiqhttp.check:allow", 1)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// remoteIP extracts the caller's IP from r.RemoteAddr, falling back to
+// treating the whole value as an IP if it has no port.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// refererURL returns the Referer header, which is the closest net/http
+// equivalent of the URL the ad was placed on.
+func refererURL(r *http.Request) string {
+	return r.Header.Get("Referer")
+}
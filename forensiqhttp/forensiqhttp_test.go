@@ -0,0 +1,142 @@
+package forensiqhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dailymotion/forensiq"
+)
+
+func newStub(t *testing.T, body string, delay time.Duration) (*forensiq.Forensiq, func()) {
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	})
+	ts := httptest.NewServer(m)
+	return &forensiq.Forensiq{ClientKey: "123abc", Host: ts.URL}, ts.Close
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func doRequest(h http.Handler) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "1.2.3.4:5678"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestMiddlewareAllows(t *testing.T) {
+	f, closeFn := newStub(t, `{"riskScore":0,"nonSuspect":true}`, 0)
+	defer closeFn()
+
+	h := Middleware(f, Options{RiskThreshold: 65})(okHandler())
+	rec := doRequest(h)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status: want %d got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestMiddlewareBlocks(t *testing.T) {
+	f, closeFn := newStub(t, `{"riskScore":90}`, 0)
+	defer closeFn()
+
+	h := Middleware(f, Options{RiskThreshold: 65})(okHandler())
+	rec := doRequest(h)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status: want %d got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestMiddlewareTimeoutFailClosed(t *testing.T) {
+	f, closeFn := newStub(t, `{"riskScore":0}`, 50*time.Millisecond)
+	defer closeFn()
+
+	h := Middleware(f, Options{RiskThreshold: 65, Timeout: time.Millisecond})(okHandler())
+	rec := doRequest(h)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status: want %d got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestMiddlewareTimeoutFailOpen(t *testing.T) {
+	f, closeFn := newStub(t, `{"riskScore":0}`, 50*time.Millisecond)
+	defer closeFn()
+
+	h := Middleware(f, Options{RiskThreshold: 65, Timeout: time.Millisecond, FailOpen: true})(okHandler())
+	rec := doRequest(h)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status: want %d got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestMiddlewareRequestType(t *testing.T) {
+	var gotRT string
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		gotRT = r.URL.Query().Get("rt")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"riskScore":0}`))
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+	f := &forensiq.Forensiq{ClientKey: "123abc", Host: ts.URL}
+
+	doRequest(Middleware(f, Options{RiskThreshold: 65})(okHandler()))
+	if gotRT != "display" {
+		t.Errorf("default RequestType: want %q got %q", "display", gotRT)
+	}
+
+	doRequest(Middleware(f, Options{
+		RiskThreshold: 65,
+		RequestType:   func(*http.Request) string { return "click" },
+	})(okHandler()))
+	if gotRT != "click" {
+		t.Errorf("custom RequestType: want %q got %q", "click", gotRT)
+	}
+}
+
+func TestMiddlewareTimeoutDoesNotLeakIntoHandler(t *testing.T) {
+	f, closeFn := newStub(t, `{"riskScore":0}`, 0)
+	defer closeFn()
+
+	var sawDeadline bool
+	h := Middleware(f, Options{RiskThreshold: 65, Timeout: 50 * time.Millisecond})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, sawDeadline = r.Context().Deadline()
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	doRequest(h)
+	if sawDeadline {
+		t.Error("downstream handler's context carries Options.Timeout's deadline, it should not")
+	}
+}
+
+func TestMiddlewareBypass(t *testing.T) {
+	f, closeFn := newStub(t, `{"riskScore":100}`, 0)
+	defer closeFn()
+
+	h := Middleware(f, Options{
+		RiskThreshold: 65,
+		Bypass:        func(r *http.Request) bool { return r.URL.Path == "/healthz" },
+	})(okHandler())
+
+	r := httptest.NewRequest("GET", "/healthz", nil)
+	r.RemoteAddr = "1.2.3.4:5678"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status: want %d got %d", http.StatusOK, rec.Code)
+	}
+}
@@ -0,0 +1,173 @@
+package forensiq
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/rs/xstats"
+
+	"golang.org/x/net/context"
+)
+
+// ErrCircuitOpen is returned by Check when the circuit breaker installed via
+// SetPolicy is open and short-circuiting requests.
+var ErrCircuitOpen = errors.New("forensiq: circuit breaker is open")
+
+// OnErrorPolicy controls what Check returns once the resilience policy gives
+// up on a request: retries exhausted, or the circuit breaker open.
+type OnErrorPolicy int
+
+const (
+	// PolicyFail returns the underlying error to the caller. This is the
+	// zero value and matches Check's pre-Policy behavior.
+	PolicyFail OnErrorPolicy = iota
+	// PolicyAllow synthesizes CheckResponse{NonSuspect: true}, failing open.
+	PolicyAllow
+	// PolicyDeny synthesizes CheckResponse{RiskScore: 100}, failing closed.
+	PolicyDeny
+)
+
+// Policy configures retry and circuit-breaker behavior around Check's
+// outbound HTTP call. ErrInvalidClientKey is never retried or counted
+// against the breaker since it indicates a configuration error, not a
+// transient failure.
+type Policy struct {
+	// MaxRetries is the number of additional attempts made after the first
+	// one, for 5xx responses and network errors.
+	MaxRetries int
+	// Backoff returns how long to wait before retry number attempt
+	// (1-based). Defaults to exponential backoff with jitter.
+	Backoff func(attempt int) time.Duration
+	// OnError controls the response returned to the caller once retries are
+	// exhausted or the circuit breaker is open.
+	OnError OnErrorPolicy
+	// BreakerThreshold is the number of consecutive failures within
+	// BreakerWindow that trips the breaker. Zero disables it.
+	BreakerThreshold int
+	// BreakerWindow is the time window consecutive failures are counted in.
+	BreakerWindow time.Duration
+	// BreakerCooldown is how long the breaker stays open before letting a
+	// single half-open probe through.
+	BreakerCooldown time.Duration
+}
+
+// maxBackoff caps defaultBackoff so a high MaxRetries can never shift its
+// base duration into overflow.
+const maxBackoff = 30 * time.Second
+
+// defaultBackoff is exponential with full jitter: [0, min(2^(attempt-1) *
+// 100ms, maxBackoff)].
+func defaultBackoff(attempt int) time.Duration {
+	base := maxBackoff
+	if shift := uint(attempt - 1); shift < 9 {
+		if d := 100 * time.Millisecond << shift; d < maxBackoff {
+			base = d
+		}
+	}
+	return time.Duration(rand.Int63n(int64(base) + 1))
+}
+
+// SetPolicy installs p as the resilience policy used by Check. Calling it
+// again replaces the existing policy and breaker state.
+func (f *Forensiq) SetPolicy(p Policy) {
+	f.policy = &p
+	f.breaker = nil
+	if p.BreakerThreshold > 0 {
+		f.breaker = newBreaker(p.BreakerThreshold, p.BreakerWindow, p.BreakerCooldown)
+	}
+}
+
+// checkResilient wraps check with f.policy's retry and circuit breaker
+// behavior, if a policy was installed via SetPolicy. Only errors check
+// classified as transient (5xx responses, transport failures) are retried
+// or counted against the breaker; decode failures and ErrInvalidClientKey
+// are returned as-is. The returned error is the raw, unmapped outcome (never
+// synthesized by OnError) so callers — Check's cache included — can tell a
+// genuine failure from a success; applyPolicy does the OnError mapping.
+func (f *Forensiq) checkResilient(ctx context.Context, creq CheckRequest) (CheckResponse, error) {
+	if f.policy == nil {
+		cresp, err := f.check(ctx, creq)
+		return cresp, unwrapTransient(err)
+	}
+	p := f.policy
+
+	sts := xstats.FromContext(ctx)
+
+	if f.breaker != nil {
+		allowed, enteredHalfOpen := f.breaker.allow()
+		if enteredHalfOpen {
+			sts.Increment("forensiq.breaker:half_open", 1)
+		}
+		if !allowed {
+			sts.Increment("forensiq.breaker:short_circuit", 1)
+			return CheckResponse{}, ErrCircuitOpen
+		}
+	}
+
+	backoff := p.Backoff
+	if backoff == nil {
+		backoff = defaultBackoff
+	}
+
+	var (
+		cresp    CheckResponse
+		err      error
+		canceled bool
+	)
+retry:
+	for attempt := 0; ; attempt++ {
+		cresp, err = f.check(ctx, creq)
+		if err == nil || !isTransient(err) || attempt >= p.MaxRetries {
+			break
+		}
+		sts.Increment("forensiq.retry:attempt", 1, "attempt:"+strconv.Itoa(attempt+1))
+		select {
+		case <-time.After(backoff(attempt + 1)):
+		case <-ctx.Done():
+			err = ctx.Err()
+			canceled = true
+			break retry
+		}
+	}
+
+	if ctx.Err() != nil {
+		// The caller's context was canceled or timed out; that says nothing
+		// about Forensiq's health, so it must never count against the
+		// breaker.
+		canceled = true
+	}
+
+	if f.breaker != nil && !canceled {
+		if isTransient(err) {
+			if tripped := f.breaker.recordFailure(); tripped {
+				sts.Increment("forensiq.breaker:open", 1)
+			}
+			sts.Increment("forensiq.breaker:failure", 1)
+		} else {
+			if reset := f.breaker.recordSuccess(); reset {
+				sts.Increment("forensiq.breaker:closed", 1)
+			}
+		}
+	}
+
+	return cresp, unwrapTransient(err)
+}
+
+// applyPolicy maps a checkResilient outcome through f.policy's OnError, if
+// one was installed. ErrInvalidClientKey is never masked since it signals a
+// configuration error rather than something OnError should paper over.
+func (f *Forensiq) applyPolicy(cresp CheckResponse, err error) (CheckResponse, error) {
+	if err == nil || err == ErrInvalidClientKey || f.policy == nil {
+		return cresp, err
+	}
+	switch f.policy.OnError {
+	case PolicyAllow:
+		return CheckResponse{NonSuspect: true}, nil
+	case PolicyDeny:
+		return CheckResponse{RiskScore: 100}, nil
+	default:
+		return cresp, err
+	}
+}
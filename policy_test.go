@@ -0,0 +1,187 @@
+package forensiq
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func noJitterBackoff(attempt int) time.Duration { return time.Millisecond }
+
+func TestCheckRetriesOn5xx(t *testing.T) {
+	var calls int32
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"nonSuspect":true}`))
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	f := &Forensiq{ClientKey: "123abc", Host: ts.URL}
+	f.SetPolicy(Policy{MaxRetries: 2, Backoff: noJitterBackoff})
+
+	cresp, err := f.Check(context.Background(), CheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cresp.NonSuspect {
+		t.Errorf("cresp.NonSuspect: want true got false")
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("server calls: want 3 got %d", got)
+	}
+}
+
+func TestCheckNeverRetriesInvalidClientKey(t *testing.T) {
+	var calls int32
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	f := &Forensiq{Host: ts.URL}
+	f.SetPolicy(Policy{MaxRetries: 3, Backoff: noJitterBackoff})
+
+	_, err := f.Check(context.Background(), CheckRequest{})
+	if err != ErrInvalidClientKey {
+		t.Errorf("err: want %v got %v", ErrInvalidClientKey, err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("server calls: want 1 got %d", got)
+	}
+}
+
+func TestCheckOnErrorPolicies(t *testing.T) {
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	tests := map[OnErrorPolicy]CheckResponse{
+		PolicyAllow: {NonSuspect: true},
+		PolicyDeny:  {RiskScore: 100},
+	}
+	for policy, want := range tests {
+		f := &Forensiq{Host: ts.URL}
+		f.SetPolicy(Policy{MaxRetries: 0, OnError: policy})
+
+		cresp, err := f.Check(context.Background(), CheckRequest{})
+		if err != nil {
+			t.Fatalf("policy %v: unexpected error %v", policy, err)
+		}
+		if cresp != want {
+			t.Errorf("policy %v: want %+v got %+v", policy, want, cresp)
+		}
+	}
+}
+
+func TestCheckCircuitBreakerOpens(t *testing.T) {
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	f := &Forensiq{Host: ts.URL}
+	f.SetPolicy(Policy{
+		MaxRetries:       0,
+		BreakerThreshold: 2,
+		BreakerWindow:    time.Minute,
+		BreakerCooldown:  time.Hour,
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := f.Check(context.Background(), CheckRequest{}); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+
+	_, err := f.Check(context.Background(), CheckRequest{})
+	if err != ErrCircuitOpen {
+		t.Errorf("err: want %v got %v", ErrCircuitOpen, err)
+	}
+}
+
+func TestCheck5xxWithValidJSONIsAnError(t *testing.T) {
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{}`))
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	// No policy installed: check's own 5xx classification must hold on its
+	// own, not just as a side effect of checkResilient's retry logic.
+	f := &Forensiq{Host: ts.URL}
+	if _, err := f.Check(context.Background(), CheckRequest{}); err == nil {
+		t.Fatal("want an error for a 5xx response, got nil")
+	}
+}
+
+func TestCheckMalformed200NotRetried(t *testing.T) {
+	var calls int32
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`not json`))
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	f := &Forensiq{Host: ts.URL}
+	f.SetPolicy(Policy{MaxRetries: 3, Backoff: noJitterBackoff})
+
+	if _, err := f.Check(context.Background(), CheckRequest{}); err == nil {
+		t.Fatal("want a decode error, got nil")
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("server calls: want 1 (no retry on decode failure) got %d", got)
+	}
+}
+
+func TestCheckCanceledContextDoesNotTripBreaker(t *testing.T) {
+	m := http.NewServeMux()
+	m.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	})
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	f := &Forensiq{Host: ts.URL}
+	f.SetPolicy(Policy{
+		MaxRetries:       0,
+		BreakerThreshold: 1,
+		BreakerWindow:    time.Minute,
+		BreakerCooldown:  time.Hour,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := f.Check(ctx, CheckRequest{}); err == nil {
+		t.Fatal("want a timeout error, got nil")
+	}
+
+	// The breaker must still be closed: a client-side timeout says nothing
+	// about Forensiq's health.
+	if ok, _ := f.breaker.allow(); !ok {
+		t.Error("breaker tripped on a caller context timeout")
+	}
+}
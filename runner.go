@@ -0,0 +1,123 @@
+package forensiq
+
+import (
+	"errors"
+	"sync"
+
+	"golang.org/x/net/context"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrQueueFull is returned by Runner.Submit when the queue is full and
+// RunnerOptions.Block is false.
+var ErrQueueFull = errors.New("forensiq: runner queue is full")
+
+// RunnerOptions configures NewRunner.
+type RunnerOptions struct {
+	// MaxInFlight bounds how many requests the Runner processes concurrently.
+	// Defaults to 1.
+	MaxInFlight int
+	// QueueSize bounds how many submitted requests may wait for a free
+	// worker before Submit applies backpressure.
+	QueueSize int
+	// Block makes Submit block until there is room in the queue instead of
+	// returning ErrQueueFull.
+	Block bool
+}
+
+type runnerJob struct {
+	ctx  context.Context
+	creq CheckRequest
+	resp chan CheckResult
+}
+
+// Runner accepts individual CheckRequests on a queue and processes them on a
+// bounded pool of workers, coalescing concurrent duplicate in-flight lookups
+// (same IP/UserAgent/SellerID) into a single upstream call via singleflight.
+// It is intended for high-QPS callers that would otherwise have to serialize
+// Check calls or open many sockets themselves.
+type Runner struct {
+	f     *Forensiq
+	block bool
+	queue chan runnerJob
+	sf    singleflight.Group
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewRunner starts a Runner backed by f with the given options and returns
+// it. Call Stop to release its workers.
+func NewRunner(f *Forensiq, opts RunnerOptions) *Runner {
+	if opts.MaxInFlight <= 0 {
+		opts.MaxInFlight = 1
+	}
+
+	r := &Runner{
+		f:     f,
+		block: opts.Block,
+		queue: make(chan runnerJob, opts.QueueSize),
+		quit:  make(chan struct{}),
+	}
+
+	r.wg.Add(opts.MaxInFlight)
+	for i := 0; i < opts.MaxInFlight; i++ {
+		go r.work()
+	}
+
+	return r
+}
+
+func (r *Runner) work() {
+	defer r.wg.Done()
+	for {
+		select {
+		case job := <-r.queue:
+			r.process(job)
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+func (r *Runner) process(job runnerJob) {
+	v, err, _ := r.sf.Do(cacheKey(job.creq), func() (interface{}, error) {
+		return r.f.Check(job.ctx, job.creq)
+	})
+	cresp, _ := v.(CheckResponse)
+
+	// job.resp is buffered with room for this one result, so the send never
+	// blocks, even if job.ctx is done.
+	job.resp <- CheckResult{Response: cresp, Err: err}
+}
+
+// Submit enqueues creq for processing and returns a channel that receives
+// exactly one CheckResult once it has been handled. If the queue is full,
+// Submit blocks when RunnerOptions.Block is set and returns ErrQueueFull
+// otherwise.
+func (r *Runner) Submit(ctx context.Context, creq CheckRequest) (<-chan CheckResult, error) {
+	job := runnerJob{ctx: ctx, creq: creq, resp: make(chan CheckResult, 1)}
+
+	if r.block {
+		select {
+		case r.queue <- job:
+			return job.resp, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	select {
+	case r.queue <- job:
+		return job.resp, nil
+	default:
+		return nil, ErrQueueFull
+	}
+}
+
+// Stop shuts down the Runner's workers, letting in-flight jobs finish.
+// Queued-but-not-started jobs never receive a result.
+func (r *Runner) Stop() {
+	close(r.quit)
+	r.wg.Wait()
+}